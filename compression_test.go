@@ -0,0 +1,94 @@
+package melody
+
+import (
+	"compress/flate"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestHandleRequestConcurrentDialsNoRace reproduces the data race found in
+// review: concurrent dials used to race a per-request write to
+// m.Upgrader.EnableCompression against gorilla's own read of that field
+// inside Upgrade. Run with -race; it only fails if that mutation comes back.
+func TestHandleRequestConcurrentDialsNoRace(t *testing.T) {
+	m := New()
+	defer m.Close()
+	m.Config.EnableCompression = true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = m.HandleRequest(w, r)
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	const dialers = 20
+	var wg sync.WaitGroup
+	wg.Add(dialers)
+	for i := 0; i < dialers; i++ {
+		go func() {
+			defer wg.Done()
+
+			dialer := *websocket.DefaultDialer
+			dialer.EnableCompression = true
+
+			conn, _, err := dialer.Dial(wsURL, nil)
+			if err != nil {
+				t.Errorf("dial: %v", err)
+				return
+			}
+			_ = conn.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSetCompressionLevelAcceptsDefaultCompression(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	if err := s.SetCompressionLevel(flate.DefaultCompression); err != nil {
+		t.Fatalf("expected flate.DefaultCompression to be accepted, got %v", err)
+	}
+}
+
+func TestSetCompressionLevelRejectsOutOfRange(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	if err := s.SetCompressionLevel(flate.HuffmanOnly - 1); err == nil {
+		t.Fatal("expected an error for a level below flate.HuffmanOnly")
+	}
+
+	if err := s.SetCompressionLevel(flate.BestCompression + 1); err == nil {
+		t.Fatal("expected an error for a level above flate.BestCompression")
+	}
+}
+
+func TestWriteUncompressedOverridesEnvelopeCompression(t *testing.T) {
+	m := New()
+	defer m.Close()
+	m.Config.HistorySize = 0
+
+	s := newTestSession(m, 1)
+
+	if err := s.WriteUncompressed([]byte("hi")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	e := <-s.output
+	if e.compress == nil || *e.compress != false {
+		t.Fatalf("expected envelope.compress to be a pointer to false, got %v", e.compress)
+	}
+}
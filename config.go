@@ -0,0 +1,67 @@
+package melody
+
+import (
+	"compress/flate"
+	"time"
+)
+
+// Config melody configuration struct.
+type Config struct {
+	WriteWait         time.Duration // Duration until write times out.
+	PongWait          time.Duration // Timeout for waiting on pong.
+	PingPeriod        time.Duration // Duration between pings. Must be less than PongWait.
+	MaxMessageSize    int64         // Maximum size in bytes of a message.
+	MessageBufferSize int           // The max amount of messages that can be in a session's buffer; MessageQueueOverflowPolicy decides what happens when it is full.
+	EnableCompression bool          // Enable RFC 7692 permessage-deflate compression. Applied to Upgrader.EnableCompression once in New(); mutate m.Upgrader directly to change it afterwards.
+	CompressionLevel  int           // flate compression level used when EnableCompression is true: flate.HuffmanOnly (-2) to flate.BestCompression (9), or flate.DefaultCompression (-1).
+
+	// MessageQueueOverflowPolicy controls what happens when a session's output
+	// queue (sized by MessageBufferSize) is full. Defaults to BlockPolicy.
+	MessageQueueOverflowPolicy MessageQueueOverflowPolicy
+
+	// HistorySize is the number of recently broadcast envelopes kept per topic
+	// for replay to late-joining sessions. Zero (the default) disables history.
+	HistorySize int
+
+	// HistoryTopicFn derives the topic a broadcast message is stored under,
+	// from the message payload alone. When nil, all history is recorded
+	// under one topic.
+	HistoryTopicFn func([]byte) string
+
+	// SessionTopicFn derives the topic to replay for a newly connected
+	// session, from the session alone (e.g. a room it was pre-assigned via
+	// Keys). When nil, the default topic is replayed. It is the caller's
+	// responsibility to make this agree with HistoryTopicFn's scheme.
+	SessionTopicFn func(*Session) string
+
+	// AutoReplayHistoryOnConnect replays the relevant topic's history into a
+	// session's output as soon as it connects, before the connect handler
+	// runs and before the session can receive any broadcast.
+	AutoReplayHistoryOnConnect bool
+
+	// MaxReplayAge bounds how old a replayed history entry may be. Zero means
+	// no age limit.
+	MaxReplayAge time.Duration
+
+	// StatsObserver, if set, is notified of session and message lifecycle
+	// events so callers can bridge them to an external metrics system.
+	StatsObserver StatsObserver
+}
+
+// newConfig returns a Config with default values.
+func newConfig() *Config {
+	return &Config{
+		WriteWait:         10 * time.Second,
+		PongWait:          60 * time.Second,
+		PingPeriod:        (60 * time.Second * 9) / 10,
+		MaxMessageSize:    512,
+		MessageBufferSize: 256,
+		EnableCompression: false,
+		CompressionLevel:  flate.DefaultCompression,
+
+		MessageQueueOverflowPolicy: BlockPolicy,
+
+		HistorySize:                0,
+		AutoReplayHistoryOnConnect: false,
+	}
+}
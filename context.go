@@ -0,0 +1,80 @@
+package melody
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrSessionClosed is returned by the *Context write methods instead of the
+// session's output channel send panicking when the session has already
+// transitioned to StatusStop.
+var ErrSessionClosed = errors.New("melody: session is closed")
+
+// Context returns a context.Context that is cancelled once the session
+// transitions to StatusStop, whether that happens through Close, a read
+// error, or the CloseHandler. Callers can select on it to notice a
+// disconnect instead of blocking forever on a stuck write.
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+// WriteContext writes a text message to the session, aborting with
+// ErrSessionClosed if the session closes first or ctx's error if ctx is
+// done first.
+func (s *Session) WriteContext(ctx context.Context, msg []byte) error {
+	return s.writeContext(ctx, &envelope{t: websocket.TextMessage, msg: msg})
+}
+
+// WriteBinaryContext writes a binary message to the session, aborting with
+// ErrSessionClosed if the session closes first or ctx's error if ctx is
+// done first.
+func (s *Session) WriteBinaryContext(ctx context.Context, msg []byte) error {
+	return s.writeContext(ctx, &envelope{t: websocket.BinaryMessage, msg: msg})
+}
+
+func (s *Session) writeContext(ctx context.Context, message *envelope) error {
+	// Held for the duration of the send so close() cannot close s.output out
+	// from under us; close() takes the write lock before closing it.
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+
+	if s.closed() {
+		return ErrSessionClosed
+	}
+
+	select {
+	case s.output <- message:
+		return nil
+	case <-s.ctx.Done():
+		return ErrSessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BroadcastContext broadcasts a text message to all sessions, same as
+// Broadcast, but stops visiting further sessions as soon as ctx is done.
+func (m *Melody) BroadcastContext(ctx context.Context, msg []byte) error {
+	if m.hub.closed() {
+		return errHubClosed
+	}
+
+	message := &envelope{t: websocket.TextMessage, msg: msg}
+
+	m.hub.rwmutex.RLock()
+	defer m.hub.rwmutex.RUnlock()
+
+	for s := range m.hub.sessions {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		s.writeMessage(message)
+	}
+
+	return nil
+}
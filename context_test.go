@@ -0,0 +1,96 @@
+package melody
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessionContextCancelledOnClose(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	select {
+	case <-s.Context().Done():
+		t.Fatal("context should not be done before close")
+	default:
+	}
+
+	s.close()
+
+	select {
+	case <-s.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("context was not cancelled by close")
+	}
+}
+
+// TestWriteContextUnblocksOnClose guards against the deadlock where
+// writeContext holds s.rwMutex.RLock() for its blocking select while
+// close() waits on s.rwMutex.Lock() to cancel s.ctx: if cancellation were
+// gated behind that same lock, neither goroutine could ever proceed.
+func TestWriteContextUnblocksOnClose(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	// Fill the buffer so the next WriteContext call blocks; nothing is
+	// draining s.output since writePump was never started for this session.
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("a")}); err != nil {
+		t.Fatalf("fill buffer: %v", err)
+	}
+
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- s.WriteContext(context.Background(), []byte("b"))
+	}()
+
+	time.Sleep(20 * time.Millisecond) // let the goroutine above block on the full channel
+
+	closeDone := make(chan struct{})
+	go func() {
+		s.close()
+		close(closeDone)
+	}()
+
+	select {
+	case <-closeDone:
+	case <-time.After(time.Second):
+		t.Fatal("close() deadlocked waiting on a blocked WriteContext caller")
+	}
+
+	select {
+	case err := <-writeErr:
+		if err != ErrSessionClosed {
+			t.Fatalf("expected ErrSessionClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WriteContext did not return after close()")
+	}
+}
+
+func TestWriteContextRespectsCallerContext(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("a")}); err != nil {
+		t.Fatalf("fill buffer: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := s.WriteContext(ctx, []byte("b")); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	s.close()
+}
@@ -0,0 +1,9 @@
+package melody
+
+type envelope struct {
+	t   int
+	msg []byte
+	// compress overrides the connection's default write compression for this
+	// envelope when non-nil.
+	compress *bool
+}
@@ -0,0 +1,51 @@
+package melody
+
+import (
+	"sync"
+	"time"
+)
+
+type historyItem struct {
+	env *envelope
+	at  time.Time
+}
+
+// historyStore keeps a bounded, per-topic ring of recently broadcast
+// envelopes so they can be replayed to sessions that connect late.
+type historyStore struct {
+	mu      sync.Mutex
+	buffers map[string][]historyItem
+}
+
+func newHistoryStore() *historyStore {
+	return &historyStore{buffers: make(map[string][]historyItem)}
+}
+
+func (hs *historyStore) add(topic string, e *envelope, maxSize int) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	items := append(hs.buffers[topic], historyItem{env: e, at: time.Now()})
+	if len(items) > maxSize {
+		items = items[len(items)-maxSize:]
+	}
+	hs.buffers[topic] = items
+}
+
+func (hs *historyStore) replay(topic string, maxAge time.Duration) []*envelope {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	items := hs.buffers[topic]
+	envelopes := make([]*envelope, 0, len(items))
+	cutoff := time.Now().Add(-maxAge)
+
+	for _, item := range items {
+		if maxAge > 0 && item.at.Before(cutoff) {
+			continue
+		}
+		envelopes = append(envelopes, item.env)
+	}
+
+	return envelopes
+}
@@ -0,0 +1,108 @@
+package melody
+
+import (
+	"testing"
+	"time"
+)
+
+func envelopeMsgs(envelopes []*envelope) []string {
+	out := make([]string, len(envelopes))
+	for i, e := range envelopes {
+		out[i] = string(e.msg)
+	}
+	return out
+}
+
+func TestHistoryStoreEvictsOldestBeyondSize(t *testing.T) {
+	hs := newHistoryStore()
+
+	hs.add("room", &envelope{t: 1, msg: []byte("1")}, 2)
+	hs.add("room", &envelope{t: 1, msg: []byte("2")}, 2)
+	hs.add("room", &envelope{t: 1, msg: []byte("3")}, 2) // should evict "1"
+
+	got := envelopeMsgs(hs.replay("room", 0))
+	want := []string{"2", "3"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected %v in order, got %v", want, got)
+	}
+
+	if got := hs.replay("other-room", 0); len(got) != 0 {
+		t.Fatalf("expected no history for an unrelated topic, got %v", envelopeMsgs(got))
+	}
+}
+
+func TestHistoryStoreMaxAge(t *testing.T) {
+	hs := newHistoryStore()
+
+	hs.add("room", &envelope{t: 1, msg: []byte("old")}, 10)
+	time.Sleep(20 * time.Millisecond)
+	hs.add("room", &envelope{t: 1, msg: []byte("new")}, 10)
+
+	got := envelopeMsgs(hs.replay("room", 10*time.Millisecond))
+	if len(got) != 1 || got[0] != "new" {
+		t.Fatalf("expected only the recent entry, got %v", got)
+	}
+}
+
+func TestSessionReplayHistoryPrecedesLiveWrites(t *testing.T) {
+	m := New()
+	defer m.Close()
+	m.Config.HistorySize = 10
+
+	if err := m.BroadcastWithHistory([]byte("first")); err != nil {
+		t.Fatalf("broadcast: %v", err)
+	}
+	// Give the hub goroutine a moment to fan the broadcast out; the history
+	// entry itself is recorded before BroadcastWithHistory returns.
+	time.Sleep(20 * time.Millisecond)
+
+	s := newTestSession(m, 10)
+
+	if err := s.ReplayHistory(""); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if err := s.Write([]byte("live")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	first := <-s.output
+	second := <-s.output
+	if string(first.msg) != "first" || string(second.msg) != "live" {
+		t.Fatalf("expected replayed history (%q) before the live write (%q), got %q then %q",
+			"first", "live", first.msg, second.msg)
+	}
+}
+
+func TestHistoryTopicFnNeverSeesTheOtherSide(t *testing.T) {
+	m := New()
+	defer m.Close()
+	m.Config.HistorySize = 10
+
+	m.Config.HistoryTopicFn = func(msg []byte) string {
+		if msg == nil {
+			t.Fatal("HistoryTopicFn must always receive the broadcast message")
+		}
+		return "room"
+	}
+	m.Config.SessionTopicFn = func(s *Session) string {
+		if s == nil {
+			t.Fatal("SessionTopicFn must always receive the session")
+		}
+		return "room"
+	}
+
+	if err := m.BroadcastWithHistory([]byte("hello")); err != nil {
+		t.Fatalf("broadcast: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	s := newTestSession(m, 10)
+	if err := s.ReplayHistory(m.historyTopicForSession(s)); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	replayed := <-s.output
+	if string(replayed.msg) != "hello" {
+		t.Fatalf("expected the broadcast history to replay, got %q", replayed.msg)
+	}
+}
@@ -0,0 +1,80 @@
+package melody
+
+import (
+	"sync"
+	"time"
+)
+
+type hub struct {
+	sessions   map[*Session]bool
+	broadcast  chan *envelope
+	register   chan *Session
+	unregister chan *Session
+	exit       chan struct{}
+	open       bool
+	rwmutex    *sync.RWMutex
+	fanout     *fanoutHistogram
+}
+
+func newHub() *hub {
+	return &hub{
+		sessions:   make(map[*Session]bool),
+		broadcast:  make(chan *envelope),
+		register:   make(chan *Session),
+		unregister: make(chan *Session),
+		exit:       make(chan struct{}),
+		open:       true,
+		rwmutex:    &sync.RWMutex{},
+		fanout:     &fanoutHistogram{},
+	}
+}
+
+func (h *hub) run() {
+	for {
+		select {
+		case s := <-h.register:
+			h.rwmutex.Lock()
+			h.sessions[s] = true
+			h.rwmutex.Unlock()
+		case s := <-h.unregister:
+			if _, ok := h.sessions[s]; ok {
+				h.rwmutex.Lock()
+				delete(h.sessions, s)
+				h.rwmutex.Unlock()
+			}
+		case m := <-h.broadcast:
+			start := time.Now()
+			h.rwmutex.RLock()
+			for s := range h.sessions {
+				s.writeMessage(m)
+			}
+			h.rwmutex.RUnlock()
+			h.fanout.observe(time.Since(start))
+		case <-h.exit:
+			h.rwmutex.Lock()
+			h.open = false
+			for s := range h.sessions {
+				delete(h.sessions, s)
+				s.close()
+			}
+			h.rwmutex.Unlock()
+			return
+		}
+	}
+}
+
+func (h *hub) closed() bool {
+	h.rwmutex.RLock()
+	defer h.rwmutex.RUnlock()
+	return !h.open
+}
+
+func (h *hub) len() int {
+	h.rwmutex.RLock()
+	defer h.rwmutex.RUnlock()
+	return len(h.sessions)
+}
+
+func (h *hub) close() {
+	h.exit <- struct{}{}
+}
@@ -0,0 +1,303 @@
+package melody
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+var errHubClosed = errors.New("melody instance is closed")
+
+type handleMessageFunc func(*Session, []byte)
+type handleErrorFunc func(*Session, error)
+type handleCloseFunc func(*Session, int, string) error
+type handleSessionFunc func(*Session)
+type handleDroppedFunc func(*Session, []byte)
+
+// Melody implements a websocket manager.
+type Melody struct {
+	Config                   *Config
+	Upgrader                 *websocket.Upgrader
+	messageHandler           handleMessageFunc
+	messageHandlerBinary     handleMessageFunc
+	messageSentHandler       handleMessageFunc
+	messageSentHandlerBinary handleMessageFunc
+	errorHandler             handleErrorFunc
+	closeHandler             handleCloseFunc
+	connectHandler           handleSessionFunc
+	disconnectHandler        handleSessionFunc
+	pongHandler              handleSessionFunc
+	messageDroppedHandler    handleDroppedFunc
+	hub                      *hub
+	history                  *historyStore
+}
+
+// New creates a new melody instance with default Upgrader and Config.
+func New() *Melody {
+	config := newConfig()
+
+	upgrader := &websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: config.EnableCompression,
+	}
+
+	hub := newHub()
+	go hub.run()
+
+	return &Melody{
+		Config:                   config,
+		Upgrader:                 upgrader,
+		messageHandler:           func(*Session, []byte) {},
+		messageHandlerBinary:     func(*Session, []byte) {},
+		messageSentHandler:       func(*Session, []byte) {},
+		messageSentHandlerBinary: func(*Session, []byte) {},
+		errorHandler:             func(*Session, error) {},
+		connectHandler:           func(*Session) {},
+		disconnectHandler:        func(*Session) {},
+		pongHandler:              func(*Session) {},
+		messageDroppedHandler:    func(*Session, []byte) {},
+		hub:                      hub,
+		history:                  newHistoryStore(),
+	}
+}
+
+// HandleConnect sets the handler called when a session connects.
+func (m *Melody) HandleConnect(fn func(*Session)) {
+	m.connectHandler = fn
+}
+
+// HandleDisconnect sets the handler called when a session disconnects.
+func (m *Melody) HandleDisconnect(fn func(*Session)) {
+	m.disconnectHandler = fn
+}
+
+// HandlePong sets the handler called when a pong is received from a session.
+func (m *Melody) HandlePong(fn func(*Session)) {
+	m.pongHandler = fn
+}
+
+// HandleMessage sets the handler called when a text message comes in.
+func (m *Melody) HandleMessage(fn func(*Session, []byte)) {
+	m.messageHandler = fn
+}
+
+// HandleMessageBinary sets the handler called when a binary message comes in.
+func (m *Melody) HandleMessageBinary(fn func(*Session, []byte)) {
+	m.messageHandlerBinary = fn
+}
+
+// HandleSentMessage sets the handler called when a text message is sent.
+func (m *Melody) HandleSentMessage(fn func(*Session, []byte)) {
+	m.messageSentHandler = fn
+}
+
+// HandleSentMessageBinary sets the handler called when a binary message is sent.
+func (m *Melody) HandleSentMessageBinary(fn func(*Session, []byte)) {
+	m.messageSentHandlerBinary = fn
+}
+
+// HandleError sets the handler called when an error occurs.
+func (m *Melody) HandleError(fn func(*Session, error)) {
+	m.errorHandler = fn
+}
+
+// HandleClose sets the handler called when a close frame is received from a session.
+func (m *Melody) HandleClose(fn func(*Session, int, string) error) {
+	m.closeHandler = fn
+}
+
+// HandleMessageDropped sets the handler called when a message is dropped
+// because of the session's MessageQueueOverflowPolicy.
+func (m *Melody) HandleMessageDropped(fn func(*Session, []byte)) {
+	m.messageDroppedHandler = fn
+}
+
+// HandleRequest upgrades the HTTP server connection to the WebSocket protocol and starts
+// the session's read and write pumps.
+func (m *Melody) HandleRequest(w http.ResponseWriter, r *http.Request) error {
+	return m.HandleRequestWithKeys(w, r, nil)
+}
+
+// HandleRequestWithKeys does the same as HandleRequest but populates the session's Keys
+// with the given map before the connect handler runs.
+func (m *Melody) HandleRequestWithKeys(w http.ResponseWriter, r *http.Request, keys map[string]interface{}) error {
+	if m.hub.closed() {
+		return errHubClosed
+	}
+
+	// m.Upgrader.EnableCompression is set once in New() from the initial
+	// Config, not here: Upgrade reads it on every call, so writing it per
+	// request would race with concurrent connections. Toggle it afterwards
+	// by mutating m.Upgrader directly, the same as any gorilla Upgrader.
+	conn, err := m.Upgrader.Upgrade(w, r, w.Header())
+	if err != nil {
+		return err
+	}
+
+	if m.Config.EnableCompression {
+		conn.EnableWriteCompression(true)
+		_ = conn.SetCompressionLevel(m.Config.CompressionLevel)
+	}
+
+	// Independent of r.Context(): the session's lifetime is tied to
+	// StatusStop, not to the upgrade request's context.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	session := &Session{
+		Request: r,
+		conn:    conn,
+		melody:  m,
+		output:  make(chan *envelope, m.Config.MessageBufferSize),
+		status:  StatusNormal,
+		rwMutex: &sync.RWMutex{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	if keys != nil {
+		for k, v := range keys {
+			session.Set(k, v)
+		}
+	}
+
+	m.observeSessionOpen(session)
+
+	// Started before the connect handler and history replay run so that
+	// nothing written to session.output (by either of them) can block the
+	// connection goroutine waiting for a reader that doesn't exist yet.
+	go session.writePump()
+
+	// Replayed before the session joins the hub and before the connect
+	// handler runs, so history is queued ahead of any live message.
+	if m.Config.AutoReplayHistoryOnConnect {
+		_ = session.ReplayHistory(m.historyTopicForSession(session))
+	}
+
+	m.hub.register <- session
+
+	m.connectHandler(session)
+
+	session.readPump()
+
+	m.hub.unregister <- session
+
+	session.close()
+	m.observeSessionClose(session)
+
+	m.disconnectHandler(session)
+
+	return nil
+}
+
+// Broadcast broadcasts a text message to all sessions.
+func (m *Melody) Broadcast(msg []byte) error {
+	if m.hub.closed() {
+		return errHubClosed
+	}
+
+	message := &envelope{t: websocket.TextMessage, msg: msg}
+	m.hub.broadcast <- message
+
+	return nil
+}
+
+// BroadcastBinary broadcasts a binary message to all sessions.
+func (m *Melody) BroadcastBinary(msg []byte) error {
+	if m.hub.closed() {
+		return errHubClosed
+	}
+
+	message := &envelope{t: websocket.BinaryMessage, msg: msg}
+	m.hub.broadcast <- message
+
+	return nil
+}
+
+// BroadcastFilter broadcasts a text message to all sessions that fn returns true for.
+func (m *Melody) BroadcastFilter(msg []byte, fn func(*Session) bool) error {
+	if m.hub.closed() {
+		return errHubClosed
+	}
+
+	message := &envelope{t: websocket.TextMessage, msg: msg}
+
+	m.hub.rwmutex.RLock()
+	defer m.hub.rwmutex.RUnlock()
+
+	for s := range m.hub.sessions {
+		if fn(s) {
+			s.writeMessage(message)
+		}
+	}
+
+	return nil
+}
+
+// BroadcastWithHistory broadcasts a text message to all sessions and records
+// it in the relevant topic's history buffer for later replay.
+func (m *Melody) BroadcastWithHistory(msg []byte) error {
+	return m.broadcastWithHistory(websocket.TextMessage, msg)
+}
+
+// BroadcastBinaryWithHistory broadcasts a binary message to all sessions and
+// records it in the relevant topic's history buffer for later replay.
+func (m *Melody) BroadcastBinaryWithHistory(msg []byte) error {
+	return m.broadcastWithHistory(websocket.BinaryMessage, msg)
+}
+
+func (m *Melody) broadcastWithHistory(t int, msg []byte) error {
+	if m.hub.closed() {
+		return errHubClosed
+	}
+
+	message := &envelope{t: t, msg: msg}
+
+	if m.Config.HistorySize > 0 {
+		m.history.add(m.historyTopicForMessage(msg), message, m.Config.HistorySize)
+	}
+
+	m.hub.broadcast <- message
+
+	return nil
+}
+
+func (m *Melody) historyTopicForMessage(msg []byte) string {
+	if m.Config.HistoryTopicFn == nil {
+		return ""
+	}
+
+	return m.Config.HistoryTopicFn(msg)
+}
+
+func (m *Melody) historyTopicForSession(s *Session) string {
+	if m.Config.SessionTopicFn == nil {
+		return ""
+	}
+
+	return m.Config.SessionTopicFn(s)
+}
+
+// Close closes the melody instance and all connected sessions.
+func (m *Melody) Close() error {
+	if m.hub.closed() {
+		return errHubClosed
+	}
+
+	m.hub.close()
+
+	return nil
+}
+
+// Len returns the number of connected sessions.
+func (m *Melody) Len() int {
+	return m.hub.len()
+}
+
+// IsClosed returns whether the melody instance is closed.
+func (m *Melody) IsClosed() bool {
+	return m.hub.closed()
+}
@@ -0,0 +1,73 @@
+package melody
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestSession builds a Session with no underlying connection, suitable
+// for exercising logic (writeMessage, ReplayHistory, ...) that never
+// touches s.conn. It must not be passed to close().
+func newTestSession(m *Melody, bufSize int) *Session {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Session{
+		melody:  m,
+		output:  make(chan *envelope, bufSize),
+		status:  StatusNormal,
+		rwMutex: &sync.RWMutex{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// newSessionWithRealConn upgrades a real client connection and returns the
+// resulting Session without starting its writePump/readPump, so tests have
+// full control over when its output channel drains. The returned cleanup
+// closes the client side of the connection and the test server.
+func newSessionWithRealConn(t *testing.T, m *Melody, bufSize int) (*Session, func()) {
+	t.Helper()
+
+	sessionCh := make(chan *Session, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := m.Upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sessionCh <- &Session{
+			Request: r,
+			conn:    conn,
+			melody:  m,
+			output:  make(chan *envelope, bufSize),
+			status:  StatusNormal,
+			rwMutex: &sync.RWMutex{},
+			ctx:     ctx,
+			cancel:  cancel,
+		}
+	}))
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	clientConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		srv.Close()
+		t.Fatalf("dial: %v", err)
+	}
+
+	s := <-sessionCh
+
+	return s, func() {
+		_ = clientConn.Close()
+		srv.Close()
+	}
+}
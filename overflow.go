@@ -0,0 +1,46 @@
+package melody
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrMessageBufferFull is returned by Write/WriteBinary when the session's
+// output queue is full and the configured MessageQueueOverflowPolicy caused
+// the message to be dropped instead of delivered.
+var ErrMessageBufferFull = errors.New("melody: session message buffer is full")
+
+type overflowKind int
+
+const (
+	overflowBlock overflowKind = iota
+	overflowDropOldest
+	overflowDropNewest
+	overflowBlockWithTimeout
+)
+
+// MessageQueueOverflowPolicy controls what happens when a session's output
+// queue is full and a new message is about to be written to it.
+type MessageQueueOverflowPolicy struct {
+	kind    overflowKind
+	timeout time.Duration
+}
+
+var (
+	// BlockPolicy blocks the caller until the session has room in its output
+	// queue. This is melody's original, default behaviour.
+	BlockPolicy = MessageQueueOverflowPolicy{kind: overflowBlock}
+
+	// DropOldestPolicy evicts the oldest queued message to make room for the
+	// new one, so the most recent messages are always the ones delivered.
+	DropOldestPolicy = MessageQueueOverflowPolicy{kind: overflowDropOldest}
+
+	// DropNewestPolicy drops the incoming message instead of queuing it.
+	DropNewestPolicy = MessageQueueOverflowPolicy{kind: overflowDropNewest}
+)
+
+// BlockWithTimeoutPolicy blocks the caller until the session has room in its
+// output queue or d elapses, whichever comes first.
+func BlockWithTimeoutPolicy(d time.Duration) MessageQueueOverflowPolicy {
+	return MessageQueueOverflowPolicy{kind: overflowBlockWithTimeout, timeout: d}
+}
@@ -0,0 +1,120 @@
+package melody
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteMessageDropNewestPolicy(t *testing.T) {
+	m := New()
+	defer m.Close()
+	m.Config.MessageQueueOverflowPolicy = DropNewestPolicy
+
+	var dropped [][]byte
+	m.HandleMessageDropped(func(s *Session, msg []byte) {
+		dropped = append(dropped, msg)
+	})
+
+	s := newTestSession(m, 1)
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("a")}); err != nil {
+		t.Fatalf("first write: unexpected error %v", err)
+	}
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("b")}); err != ErrMessageBufferFull {
+		t.Fatalf("expected ErrMessageBufferFull, got %v", err)
+	}
+
+	if len(dropped) != 1 || string(dropped[0]) != "b" {
+		t.Fatalf("expected the newest message to be reported dropped, got %q", dropped)
+	}
+
+	if queued := <-s.output; string(queued.msg) != "a" {
+		t.Fatalf("expected the original message to remain queued, got %q", queued.msg)
+	}
+}
+
+func TestWriteMessageDropOldestPolicy(t *testing.T) {
+	m := New()
+	defer m.Close()
+	m.Config.MessageQueueOverflowPolicy = DropOldestPolicy
+
+	var dropped [][]byte
+	m.HandleMessageDropped(func(s *Session, msg []byte) {
+		dropped = append(dropped, msg)
+	})
+
+	s := newTestSession(m, 1)
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("a")}); err != nil {
+		t.Fatalf("first write: unexpected error %v", err)
+	}
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("b")}); err != nil {
+		t.Fatalf("second write: unexpected error %v", err)
+	}
+
+	if len(dropped) != 1 || string(dropped[0]) != "a" {
+		t.Fatalf("expected the oldest message to be reported dropped, got %q", dropped)
+	}
+
+	if queued := <-s.output; string(queued.msg) != "b" {
+		t.Fatalf("expected the newest message to remain queued, got %q", queued.msg)
+	}
+}
+
+func TestWriteMessageBlockWithTimeoutPolicy(t *testing.T) {
+	m := New()
+	defer m.Close()
+	m.Config.MessageQueueOverflowPolicy = BlockWithTimeoutPolicy(20 * time.Millisecond)
+
+	s := newTestSession(m, 1)
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("a")}); err != nil {
+		t.Fatalf("first write: unexpected error %v", err)
+	}
+
+	start := time.Now()
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("b")}); err != ErrMessageBufferFull {
+		t.Fatalf("expected ErrMessageBufferFull after the timeout, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected the write to wait out the timeout, only waited %v", elapsed)
+	}
+}
+
+func TestWriteMessageBlockPolicyWaitsForDrain(t *testing.T) {
+	m := New()
+	defer m.Close()
+	// BlockPolicy is the default.
+
+	s := newTestSession(m, 1)
+
+	if err := s.writeMessage(&envelope{t: 1, msg: []byte("a")}); err != nil {
+		t.Fatalf("first write: unexpected error %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.writeMessage(&envelope{t: 1, msg: []byte("b")})
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("blocking write returned before the buffer was drained")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	<-s.output // drain "a", unblocking the goroutine above
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error from the previously blocked write: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked write did not complete after the buffer drained")
+	}
+}
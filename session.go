@@ -1,6 +1,8 @@
 package melody
 
 import (
+	"compress/flate"
+	"context"
 	"errors"
 	"net/http"
 	"sync"
@@ -17,27 +19,77 @@ const (
 
 // Session wrapper around websocket connections.
 type Session struct {
-	Request      *http.Request
-	Keys         sync.Map
-	conn         *websocket.Conn
-	output       chan *envelope
-	melody       *Melody
-	status       uint32
-	rwMutex      *sync.RWMutex
-	lastReadTime time.Time
+	Request       *http.Request
+	Keys          sync.Map
+	conn          *websocket.Conn
+	output        chan *envelope
+	melody        *Melody
+	status        uint32
+	rwMutex       *sync.RWMutex
+	lastReadTime  time.Time
+	overflowMutex sync.Mutex
+	connMutex     sync.Mutex
+	counters      sessionCounters
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
-func (s *Session) writeMessage(message *envelope) {
+func (s *Session) writeMessage(message *envelope) (err error) {
 	if s.closed() {
 		s.melody.errorHandler(s, errors.New("tried to write to closed a session"))
-		return
+		return errors.New("tried to write to closed a session")
 	}
 	defer func() {
 		if recover() != nil {
 			s.melody.errorHandler(s, errors.New("tried to write to closed a session"))
+			err = errors.New("tried to write to closed a session")
 		}
 	}()
-	s.output <- message
+
+	switch s.melody.Config.MessageQueueOverflowPolicy.kind {
+	case overflowDropNewest:
+		select {
+		case s.output <- message:
+		default:
+			atomic.AddUint64(&s.counters.dropped, 1)
+			s.melody.messageDroppedHandler(s, message.msg)
+			err = ErrMessageBufferFull
+		}
+	case overflowDropOldest:
+		s.overflowMutex.Lock()
+		defer s.overflowMutex.Unlock()
+		select {
+		case s.output <- message:
+		default:
+			select {
+			case dropped := <-s.output:
+				atomic.AddUint64(&s.counters.dropped, 1)
+				s.melody.messageDroppedHandler(s, dropped.msg)
+			default:
+			}
+			select {
+			case s.output <- message:
+			default:
+				atomic.AddUint64(&s.counters.dropped, 1)
+				s.melody.messageDroppedHandler(s, message.msg)
+				err = ErrMessageBufferFull
+			}
+		}
+	case overflowBlockWithTimeout:
+		timer := time.NewTimer(s.melody.Config.MessageQueueOverflowPolicy.timeout)
+		defer timer.Stop()
+		select {
+		case s.output <- message:
+		case <-timer.C:
+			atomic.AddUint64(&s.counters.dropped, 1)
+			s.melody.messageDroppedHandler(s, message.msg)
+			err = ErrMessageBufferFull
+		}
+	default:
+		s.output <- message
+	}
+
+	return err
 }
 
 func (s *Session) writeRaw(message *envelope) error {
@@ -45,13 +97,30 @@ func (s *Session) writeRaw(message *envelope) error {
 		return errors.New("tried to write to a closed session")
 	}
 
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
 	_ = s.conn.SetWriteDeadline(time.Now().Add(s.melody.Config.WriteWait))
+
+	if message.compress != nil {
+		s.conn.EnableWriteCompression(*message.compress)
+		defer s.conn.EnableWriteCompression(s.melody.Config.EnableCompression)
+	}
+
 	err := s.conn.WriteMessage(message.t, message.msg)
 
 	if err != nil {
 		return err
 	}
 
+	s.counters.recordSent(message.t, len(message.msg))
+	if message.t == websocket.PingMessage {
+		atomic.AddUint64(&s.counters.pingsSent, 1)
+	}
+	if s.melody.Config.StatsObserver != nil {
+		s.melody.Config.StatsObserver.OnMessageSent(s, message.t, len(message.msg))
+	}
+
 	return nil
 }
 
@@ -61,6 +130,11 @@ func (s *Session) closed() bool {
 
 func (s *Session) close() {
 	if !s.closed() {
+		// Cancelled before taking rwMutex: writeContext holds an RLock for
+		// the duration of its blocking select, so gating cancellation behind
+		// the write Lock below would deadlock a writeContext call that is
+		// blocked on a full, undrained output channel.
+		s.cancel()
 		s.rwMutex.Lock()
 		atomic.StoreUint32(&s.status, StatusStop)
 		_ = s.conn.Close()
@@ -113,6 +187,7 @@ func (s *Session) readPump() {
 
 	s.conn.SetPongHandler(func(string) error {
 		s.setReadDeadline()
+		atomic.AddUint64(&s.counters.pongsReceived, 1)
 		s.melody.pongHandler(s)
 		return nil
 	})
@@ -131,6 +206,11 @@ func (s *Session) readPump() {
 			break
 		}
 		s.setReadDeadline()
+		s.counters.recordReceived(t, len(message))
+		if s.melody.Config.StatsObserver != nil {
+			s.melody.Config.StatsObserver.OnMessageReceived(s, t, len(message))
+		}
+
 		if t == websocket.TextMessage {
 			s.melody.messageHandler(s, message)
 		}
@@ -155,9 +235,7 @@ func (s *Session) Write(msg []byte) error {
 		return errors.New("session is closed")
 	}
 
-	s.writeMessage(&envelope{t: websocket.TextMessage, msg: msg})
-
-	return nil
+	return s.writeMessage(&envelope{t: websocket.TextMessage, msg: msg})
 }
 
 // WriteBinary writes a binary message to session.
@@ -166,7 +244,46 @@ func (s *Session) WriteBinary(msg []byte) error {
 		return errors.New("session is closed")
 	}
 
-	s.writeMessage(&envelope{t: websocket.BinaryMessage, msg: msg})
+	return s.writeMessage(&envelope{t: websocket.BinaryMessage, msg: msg})
+}
+
+// WriteUncompressed writes a text message to session without applying
+// permessage-deflate compression, even when compression is enabled for the
+// session. Useful for payloads that are already compressed.
+func (s *Session) WriteUncompressed(msg []byte) error {
+	if s.closed() {
+		return errors.New("session is closed")
+	}
+
+	no := false
+	return s.writeMessage(&envelope{t: websocket.TextMessage, msg: msg, compress: &no})
+}
+
+// SetCompressionLevel sets the flate compression level used for this
+// session's compressed writes: flate.HuffmanOnly (-2) to flate.BestCompression
+// (9), including flate.DefaultCompression (-1), Config.CompressionLevel's own
+// default. It has no effect unless Config.EnableCompression is set.
+func (s *Session) SetCompressionLevel(level int) error {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return errors.New("invalid compression level")
+	}
+
+	return s.conn.SetCompressionLevel(level)
+}
+
+// ReplayHistory enqueues the buffered history for topic into the session's
+// output, ahead of any messages written afterwards. It is a no-op if
+// Config.HistorySize is unset or the topic has no recorded history.
+func (s *Session) ReplayHistory(topic string) error {
+	if s.closed() {
+		return errors.New("session is closed")
+	}
+
+	for _, e := range s.melody.history.replay(topic, s.melody.Config.MaxReplayAge) {
+		if err := s.writeMessage(e); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,186 @@
+package melody
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// StatsObserver lets callers bridge session and connection lifecycle events
+// to external metrics systems (Prometheus, OpenTelemetry, ...) without
+// patching melody itself. Hooks are called synchronously on the hot
+// readPump/writePump paths, so implementations should not block.
+type StatsObserver interface {
+	OnSessionOpen(s *Session)
+	OnSessionClose(s *Session)
+	OnMessageSent(s *Session, messageType int, size int)
+	OnMessageReceived(s *Session, messageType int, size int)
+}
+
+// SessionStats is a point-in-time snapshot of a session's activity counters.
+type SessionStats struct {
+	TextMessagesSent        uint64
+	TextMessagesReceived    uint64
+	BinaryMessagesSent      uint64
+	BinaryMessagesReceived  uint64
+	ControlMessagesSent     uint64
+	ControlMessagesReceived uint64
+	BytesSent               uint64
+	BytesReceived           uint64
+	PingsSent               uint64
+	PongsReceived           uint64
+	MessagesDropped         uint64
+	OutputQueueDepth        int
+	LastActivity            time.Time
+}
+
+// sessionCounters holds the atomic counters backing Session.Stats(). All
+// fields are updated with atomic ops since readPump and writePump run on
+// different goroutines.
+type sessionCounters struct {
+	textSent, textReceived       uint64
+	binarySent, binaryReceived   uint64
+	controlSent, controlReceived uint64
+	bytesSent, bytesReceived     uint64
+	pingsSent, pongsReceived     uint64
+	dropped                      uint64
+	lastActivity                 int64 // UnixNano, atomic
+}
+
+func (c *sessionCounters) touch() {
+	atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+}
+
+func (c *sessionCounters) recordSent(messageType int, size int) {
+	switch messageType {
+	case websocket.TextMessage:
+		atomic.AddUint64(&c.textSent, 1)
+	case websocket.BinaryMessage:
+		atomic.AddUint64(&c.binarySent, 1)
+	default:
+		atomic.AddUint64(&c.controlSent, 1)
+	}
+	atomic.AddUint64(&c.bytesSent, uint64(size))
+	c.touch()
+}
+
+func (c *sessionCounters) recordReceived(messageType int, size int) {
+	switch messageType {
+	case websocket.TextMessage:
+		atomic.AddUint64(&c.textReceived, 1)
+	case websocket.BinaryMessage:
+		atomic.AddUint64(&c.binaryReceived, 1)
+	default:
+		atomic.AddUint64(&c.controlReceived, 1)
+	}
+	atomic.AddUint64(&c.bytesReceived, uint64(size))
+	c.touch()
+}
+
+// Stats returns a snapshot of the session's activity counters.
+func (s *Session) Stats() SessionStats {
+	c := &s.counters
+
+	return SessionStats{
+		TextMessagesSent:        atomic.LoadUint64(&c.textSent),
+		TextMessagesReceived:    atomic.LoadUint64(&c.textReceived),
+		BinaryMessagesSent:      atomic.LoadUint64(&c.binarySent),
+		BinaryMessagesReceived:  atomic.LoadUint64(&c.binaryReceived),
+		ControlMessagesSent:     atomic.LoadUint64(&c.controlSent),
+		ControlMessagesReceived: atomic.LoadUint64(&c.controlReceived),
+		BytesSent:               atomic.LoadUint64(&c.bytesSent),
+		BytesReceived:           atomic.LoadUint64(&c.bytesReceived),
+		PingsSent:               atomic.LoadUint64(&c.pingsSent),
+		PongsReceived:           atomic.LoadUint64(&c.pongsReceived),
+		MessagesDropped:         atomic.LoadUint64(&c.dropped),
+		OutputQueueDepth:        len(s.output),
+		LastActivity:            time.Unix(0, atomic.LoadInt64(&c.lastActivity)),
+	}
+}
+
+// fanoutLatencyBounds are the upper bounds (exclusive of the implicit +Inf
+// bucket) of Melody's broadcast fan-out latency histogram.
+var fanoutLatencyBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyBucket is one bucket of a latency histogram. Count holds only the
+// observations above the previous bucket's UpperBound and up to this one;
+// buckets are not cumulative. UpperBound is zero for the final, unbounded
+// (+Inf) bucket.
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+type fanoutHistogram struct {
+	counts [8]uint64 // len(fanoutLatencyBounds) + 1
+}
+
+func (h *fanoutHistogram) observe(d time.Duration) {
+	for i, bound := range fanoutLatencyBounds {
+		if d <= bound {
+			atomic.AddUint64(&h.counts[i], 1)
+			return
+		}
+	}
+	atomic.AddUint64(&h.counts[len(fanoutLatencyBounds)], 1)
+}
+
+func (h *fanoutHistogram) snapshot() []LatencyBucket {
+	buckets := make([]LatencyBucket, 0, len(fanoutLatencyBounds)+1)
+
+	for i, bound := range fanoutLatencyBounds {
+		buckets = append(buckets, LatencyBucket{UpperBound: bound, Count: atomic.LoadUint64(&h.counts[i])})
+	}
+
+	buckets = append(buckets, LatencyBucket{Count: atomic.LoadUint64(&h.counts[len(fanoutLatencyBounds)])})
+
+	return buckets
+}
+
+// MelodyStats is an aggregate, point-in-time snapshot across all of a
+// Melody instance's active sessions.
+type MelodyStats struct {
+	ActiveSessions         int
+	TotalBytesSent         uint64
+	TotalBytesReceived     uint64
+	BroadcastFanoutLatency []LatencyBucket
+}
+
+// Stats returns an aggregate snapshot of all currently connected sessions.
+func (m *Melody) Stats() MelodyStats {
+	m.hub.rwmutex.RLock()
+	defer m.hub.rwmutex.RUnlock()
+
+	stats := MelodyStats{
+		ActiveSessions:         len(m.hub.sessions),
+		BroadcastFanoutLatency: m.hub.fanout.snapshot(),
+	}
+
+	for s := range m.hub.sessions {
+		stats.TotalBytesSent += atomic.LoadUint64(&s.counters.bytesSent)
+		stats.TotalBytesReceived += atomic.LoadUint64(&s.counters.bytesReceived)
+	}
+
+	return stats
+}
+
+func (m *Melody) observeSessionOpen(s *Session) {
+	if m.Config.StatsObserver != nil {
+		m.Config.StatsObserver.OnSessionOpen(s)
+	}
+}
+
+func (m *Melody) observeSessionClose(s *Session) {
+	if m.Config.StatsObserver != nil {
+		m.Config.StatsObserver.OnSessionClose(s)
+	}
+}
@@ -0,0 +1,123 @@
+package melody
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type recordingObserver struct {
+	opened, closed int
+	sent, received int
+}
+
+func (o *recordingObserver) OnSessionOpen(s *Session)                            { o.opened++ }
+func (o *recordingObserver) OnSessionClose(s *Session)                           { o.closed++ }
+func (o *recordingObserver) OnMessageSent(s *Session, messageType, size int)     { o.sent++ }
+func (o *recordingObserver) OnMessageReceived(s *Session, messageType, size int) { o.received++ }
+
+func TestSessionStatsTracksSentAndReceived(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	if err := s.writeRaw(&envelope{t: websocket.TextMessage, msg: []byte("hello")}); err != nil {
+		t.Fatalf("writeRaw: %v", err)
+	}
+	s.counters.recordReceived(websocket.BinaryMessage, 3)
+
+	stats := s.Stats()
+	if stats.TextMessagesSent != 1 || stats.BytesSent != 5 {
+		t.Fatalf("unexpected sent stats: %+v", stats)
+	}
+	if stats.BinaryMessagesReceived != 1 || stats.BytesReceived != 3 {
+		t.Fatalf("unexpected received stats: %+v", stats)
+	}
+}
+
+func TestStatsObserverHooksFireOnConnectAndDisconnect(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	obs := &recordingObserver{}
+	m.Config.StatsObserver = obs
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	m.observeSessionOpen(s)
+	m.observeSessionClose(s)
+	cleanup()
+
+	if obs.opened != 1 || obs.closed != 1 {
+		t.Fatalf("expected one open and one close event, got %+v", obs)
+	}
+}
+
+func TestStatsObserverHooksFireOnMessageSentAndReceived(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	obs := &recordingObserver{}
+	m.Config.StatsObserver = obs
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	if err := s.writeRaw(&envelope{t: websocket.TextMessage, msg: []byte("hi")}); err != nil {
+		t.Fatalf("writeRaw: %v", err)
+	}
+	// No readPump runs for this session, so the received side repeats
+	// readPump's own calls by hand: it checks the observer's wiring, not
+	// that readPump makes the call.
+	s.counters.recordReceived(websocket.TextMessage, 2)
+	if s.melody.Config.StatsObserver != nil {
+		s.melody.Config.StatsObserver.OnMessageReceived(s, websocket.TextMessage, 2)
+	}
+
+	if obs.sent != 1 || obs.received != 1 {
+		t.Fatalf("expected one sent and one received event, got %+v", obs)
+	}
+}
+
+func TestMelodyStatsAggregatesActiveSessions(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	m.hub.register <- s
+	defer func() { m.hub.unregister <- s }()
+
+	time.Sleep(20 * time.Millisecond) // let the hub goroutine process registration
+
+	if err := s.writeRaw(&envelope{t: websocket.TextMessage, msg: []byte("hello")}); err != nil {
+		t.Fatalf("writeRaw: %v", err)
+	}
+
+	stats := m.Stats()
+	if stats.ActiveSessions != 1 {
+		t.Fatalf("expected 1 active session, got %d", stats.ActiveSessions)
+	}
+	if stats.TotalBytesSent != 5 {
+		t.Fatalf("expected 5 total bytes sent, got %d", stats.TotalBytesSent)
+	}
+}
+
+func TestFanoutHistogramBucketsByLatency(t *testing.T) {
+	h := &fanoutHistogram{}
+
+	h.observe(500 * time.Microsecond) // <= 1ms bucket
+	h.observe(2 * time.Second)        // overflow bucket
+
+	buckets := h.snapshot()
+	if buckets[0].Count != 1 {
+		t.Fatalf("expected 1 observation in the first bucket, got %d", buckets[0].Count)
+	}
+	last := buckets[len(buckets)-1]
+	if last.UpperBound != 0 || last.Count != 1 {
+		t.Fatalf("expected 1 observation in the +Inf bucket, got %+v", last)
+	}
+}
@@ -0,0 +1,99 @@
+package melody
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamSentSentinel is passed to messageSentHandler/messageSentHandlerBinary
+// in place of the full payload for messages written via NextWriter/WriteReader,
+// since a streamed payload is never buffered in memory.
+var streamSentSentinel = []byte("<melody: streamed message>")
+
+// sessionWriter implements io.WriteCloser by streaming directly onto a
+// session's underlying connection, bypassing the buffered output queue.
+type sessionWriter struct {
+	s           *Session
+	w           io.WriteCloser
+	messageType int
+}
+
+// NextWriter returns a writer for the session's next outgoing message of the
+// given type, bypassing the output queue used by Write/WriteBinary. It takes
+// exclusive ownership of the underlying connection until the returned writer
+// is closed, so writePump (and any concurrent NextWriter caller) is blocked
+// for the duration of the stream. Suited for large payloads that should not
+// be held fully in memory as a single []byte.
+func (s *Session) NextWriter(messageType int) (io.WriteCloser, error) {
+	if s.closed() {
+		return nil, errors.New("session is closed")
+	}
+
+	s.connMutex.Lock()
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.melody.Config.WriteWait))
+	w, err := s.conn.NextWriter(messageType)
+	if err != nil {
+		s.connMutex.Unlock()
+		return nil, err
+	}
+
+	return &sessionWriter{s: s, w: w, messageType: messageType}, nil
+}
+
+func (sw *sessionWriter) Write(p []byte) (int, error) {
+	return sw.w.Write(p)
+}
+
+// Close flushes the message and releases the connection back to writePump.
+func (sw *sessionWriter) Close() error {
+	defer sw.s.connMutex.Unlock()
+
+	err := sw.w.Close()
+	if err != nil {
+		sw.s.melody.errorHandler(sw.s, err)
+		return err
+	}
+
+	switch sw.messageType {
+	case websocket.TextMessage:
+		sw.s.melody.messageSentHandler(sw.s, streamSentSentinel)
+	case websocket.BinaryMessage:
+		sw.s.melody.messageSentHandlerBinary(sw.s, streamSentSentinel)
+	}
+
+	return nil
+}
+
+// abort gives up on the in-progress message after a write-side failure such
+// as a caller's reader erroring mid-copy. sw.w.Close() is not an option here:
+// on the gorilla/websocket writer that "closes" the message by flushing
+// whatever has been written so far as a complete frame, which would report a
+// truncated payload as a successfully sent message. Closing the connection
+// outright is the only way to guarantee the peer never sees that partial
+// frame.
+func (sw *sessionWriter) abort(cause error) {
+	defer sw.s.connMutex.Unlock()
+
+	_ = sw.s.conn.Close()
+	sw.s.melody.errorHandler(sw.s, cause)
+}
+
+// WriteReader is a convenience wrapper around NextWriter that streams r's
+// contents into a single message of the given type.
+func (s *Session) WriteReader(messageType int, r io.Reader) error {
+	w, err := s.NextWriter(messageType)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.(*sessionWriter).abort(err)
+		return err
+	}
+
+	return w.Close()
+}
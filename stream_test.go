@@ -0,0 +1,98 @@
+package melody
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// errAfterFirstChunk returns its first Read normally, then fails.
+type errAfterFirstChunk struct {
+	first []byte
+	done  bool
+	err   error
+}
+
+func (r *errAfterFirstChunk) Read(p []byte) (int, error) {
+	if !r.done {
+		r.done = true
+		return copy(p, r.first), nil
+	}
+	return 0, r.err
+}
+
+func TestWriteReaderStreamsMessage(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	var sent [][]byte
+	m.HandleSentMessage(func(s *Session, msg []byte) {
+		sent = append(sent, msg)
+	})
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	if err := s.WriteReader(websocket.TextMessage, bytes.NewReader([]byte("payload"))); err != nil {
+		t.Fatalf("WriteReader: %v", err)
+	}
+
+	if len(sent) != 1 || !bytes.Equal(sent[0], streamSentSentinel) {
+		t.Fatalf("expected the sent sentinel to be reported once, got %v", sent)
+	}
+}
+
+func TestWriteReaderAbortsOnMidStreamReadError(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	var sentCount uint32
+	m.HandleSentMessage(func(s *Session, msg []byte) {
+		atomic.AddUint32(&sentCount, 1)
+	})
+
+	var reportedErr error
+	m.HandleError(func(s *Session, err error) {
+		reportedErr = err
+	})
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	wantErr := errors.New("boom")
+	r := &errAfterFirstChunk{first: []byte("partial"), err: wantErr}
+
+	err := s.WriteReader(websocket.TextMessage, r)
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected WriteReader to return the read error, got %v", err)
+	}
+
+	if atomic.LoadUint32(&sentCount) != 0 {
+		t.Fatal("truncated message must not be reported as sent")
+	}
+
+	if reportedErr != wantErr {
+		t.Fatalf("expected errorHandler to receive the read error, got %v", reportedErr)
+	}
+
+	if !s.closed() && s.conn.WriteMessage(websocket.TextMessage, []byte("x")) == nil {
+		t.Fatal("expected the connection to be closed after an aborted stream")
+	}
+}
+
+func TestNextWriterRejectsClosedSession(t *testing.T) {
+	m := New()
+	defer m.Close()
+
+	s, cleanup := newSessionWithRealConn(t, m, 1)
+	defer cleanup()
+
+	s.close()
+
+	if _, err := s.NextWriter(websocket.TextMessage); err == nil {
+		t.Fatal("expected NextWriter to reject a closed session")
+	}
+}